@@ -0,0 +1,98 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay
+
+import "sync/atomic"
+
+// Level is a logging verbosity level, ordered from most to least verbose.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Logger is the structured logging interface a Controller reports through.
+// Each method takes a message followed by alternating key/value pairs, so
+// implementations can wrap zap's SugaredLogger, slog, or anything else that
+// accepts that shape without an adapter.
+type Logger interface {
+	Debug(msg string, keyValues ...interface{})
+	Info(msg string, keyValues ...interface{})
+	Warn(msg string, keyValues ...interface{})
+	Error(msg string, keyValues ...interface{})
+}
+
+// noopLogger discards everything. It is the Controller default so the
+// library stays silent unless an integrator opts in with SetLogger.
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...interface{}) {}
+func (noopLogger) Info(string, ...interface{})  {}
+func (noopLogger) Warn(string, ...interface{})  {}
+func (noopLogger) Error(string, ...interface{}) {}
+
+// loggerHolder wraps a Logger so that c.logger (an atomic.Value) only ever
+// stores this one concrete type. atomic.Value panics if it sees two
+// different concrete types across calls to Store, which a bare Logger
+// interface value would trigger the moment SetLogger installed anything
+// other than noopLogger.
+type loggerHolder struct {
+	logger Logger
+}
+
+// SetLogger installs logger as the Controller's structured logger. Passing
+// nil restores silent, no-op logging. Safe to call while commands are in
+// flight.
+func (c *Controller) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = noopLogger{}
+	}
+	c.logger.Store(loggerHolder{logger})
+}
+
+// SetLogLevel changes the minimum Level the Controller logs at. It can be
+// called at any time, including while the Controller is in active use, so a
+// long running control process can turn up verbosity without a restart.
+func (c *Controller) SetLogLevel(level Level) {
+	atomic.StoreInt32(&c.logLevel, int32(level))
+}
+
+// log returns the currently installed Logger, defaulting to a no-op if
+// SetLogger has never been called.
+func (c *Controller) log() Logger {
+	if holder, ok := c.logger.Load().(loggerHolder); ok {
+		return holder.logger
+	}
+	return noopLogger{}
+}
+
+func (c *Controller) logEnabled(level Level) bool {
+	return level >= Level(atomic.LoadInt32(&c.logLevel))
+}
@@ -0,0 +1,237 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Sample is a single poll of every AD channel.
+type Sample struct {
+	Time time.Time
+	AD   []uint16
+}
+
+// BankChange describes an edge triggered change in a relay bank's status.
+type BankChange struct {
+	Time   time.Time
+	Bank   uint8
+	Status uint8
+}
+
+// Threshold describes an AD channel level to watch for. Hysteresis keeps a
+// reading that is oscillating right at Level from retriggering on every
+// poll: once crossed, the channel must cross back past Level by more than
+// Hysteresis before it can trigger again.
+type Threshold struct {
+	Channel    int
+	Level      uint16
+	Hysteresis uint16
+	// Rising triggers when the channel crosses Level going up; otherwise it
+	// triggers when the channel crosses Level going down.
+	Rising bool
+}
+
+// ThresholdEvent is delivered when a subscribed Threshold is crossed.
+type ThresholdEvent struct {
+	Time      time.Time
+	Value     uint16
+	Threshold Threshold
+}
+
+type thresholdSubscription struct {
+	threshold Threshold
+	ch        chan ThresholdEvent
+	armed     bool
+}
+
+// Monitor periodically polls a Controller for AD and relay bank state and
+// fans the results out to subscribers, so control loops (like the voltmeter
+// example) don't each need to build their own polling loop. One
+// ReadAllAD10 call per tick serves every AD subscriber.
+type Monitor struct {
+	controller *Controller
+	interval   time.Duration
+
+	mu         sync.Mutex
+	samples    []chan Sample
+	changes    []chan BankChange
+	thresholds []thresholdSubscription
+	seenBanks  [NumBanks]bool
+	lastBanks  [NumBanks]uint8
+}
+
+// NewMonitor creates a Monitor that polls controller every interval. Call
+// Run to start polling.
+func NewMonitor(controller *Controller, interval time.Duration) *Monitor {
+	return &Monitor{
+		controller: controller,
+		interval:   interval,
+	}
+}
+
+// Samples returns a channel that receives every AD poll. The channel is
+// buffered; a slow consumer misses samples rather than blocking the poller.
+func (m *Monitor) Samples() <-chan Sample {
+	ch := make(chan Sample, 1)
+	m.mu.Lock()
+	m.samples = append(m.samples, ch)
+	m.mu.Unlock()
+	return ch
+}
+
+// Changes returns a channel that receives a BankChange whenever a relay
+// bank's status differs from its previous poll.
+func (m *Monitor) Changes() <-chan BankChange {
+	ch := make(chan BankChange, 1)
+	m.mu.Lock()
+	m.changes = append(m.changes, ch)
+	m.mu.Unlock()
+	return ch
+}
+
+// SubscribeThreshold returns a channel that receives a ThresholdEvent each
+// time threshold is crossed, respecting threshold.Hysteresis.
+func (m *Monitor) SubscribeThreshold(threshold Threshold) <-chan ThresholdEvent {
+	ch := make(chan ThresholdEvent, 1)
+	m.mu.Lock()
+	m.thresholds = append(m.thresholds, thresholdSubscription{threshold: threshold, ch: ch, armed: true})
+	m.mu.Unlock()
+	return ch
+}
+
+// Run polls the controller every interval until ctx is canceled.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			m.poll(ctx)
+		}
+	}
+}
+
+func (m *Monitor) poll(ctx context.Context) {
+	if ad, err := m.controller.ReadAllAD10(ctx); err == nil {
+		m.publishSample(ad)
+		m.publishThresholds(ad)
+	}
+
+	for bank := uint8(0); bank < NumBanks; bank++ {
+		status, err := m.controller.GetBankStatus(ctx, bank)
+		if err != nil {
+			continue
+		}
+		m.publishChange(bank, status)
+	}
+}
+
+func (m *Monitor) publishSample(ad []uint16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sample := Sample{Time: time.Now(), AD: ad}
+	for _, ch := range m.samples {
+		select {
+		case ch <- sample:
+		default:
+		}
+	}
+}
+
+func (m *Monitor) publishChange(bank uint8, status uint8) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := m.seenBanks[bank] && m.lastBanks[bank] != status
+	m.lastBanks[bank] = status
+	m.seenBanks[bank] = true
+	if !changed {
+		return
+	}
+
+	change := BankChange{Time: time.Now(), Bank: bank, Status: status}
+	for _, ch := range m.changes {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
+
+func (m *Monitor) publishThresholds(ad []uint16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.thresholds {
+		sub := &m.thresholds[i]
+		if sub.threshold.Channel < 0 || sub.threshold.Channel >= len(ad) {
+			continue
+		}
+		value := ad[sub.threshold.Channel]
+
+		var crossed bool
+		if sub.threshold.Rising {
+			crossed = value >= sub.threshold.Level
+		} else {
+			crossed = value <= sub.threshold.Level
+		}
+
+		if crossed {
+			if !sub.armed {
+				continue
+			}
+			sub.armed = false
+			event := ThresholdEvent{Time: time.Now(), Value: value, Threshold: sub.threshold}
+			select {
+			case sub.ch <- event:
+			default:
+			}
+			continue
+		}
+
+		// Only re-arm once the value has cleared the hysteresis band, so a
+		// reading oscillating right at Level doesn't retrigger every poll.
+		if sub.threshold.Rising {
+			clearLevel := int(sub.threshold.Level) - int(sub.threshold.Hysteresis)
+			if int(value) < clearLevel {
+				sub.armed = true
+			}
+		} else {
+			clearLevel := int(sub.threshold.Level) + int(sub.threshold.Hysteresis)
+			if int(value) > clearLevel {
+				sub.armed = true
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vaelen/go-ncd-relay/relay"
+	"github.com/vaelen/go-ncd-relay/relay/relaytest"
+)
+
+func TestMonitorPublishesSamplesAndThresholdEvents(t *testing.T) {
+	fake := relaytest.NewFakeController()
+	fake.SetAD(0, 100)
+	controller := relay.New(fake.Stream())
+
+	monitor := relay.NewMonitor(controller, 5*time.Millisecond)
+	samples := monitor.Samples()
+	events := monitor.SubscribeThreshold(relay.Threshold{Channel: 0, Level: 500, Hysteresis: 10, Rising: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		monitor.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-samples:
+	case <-time.After(time.Second):
+		t.Fatal("Expected at least one sample before timeout")
+	}
+
+	fake.SetAD(0, 900)
+
+	select {
+	case event := <-events:
+		if event.Value != 900 {
+			t.Errorf("Expected threshold event value 900, got %d", event.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a threshold event after crossing Level")
+	}
+
+	cancel()
+	<-done
+}
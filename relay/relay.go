@@ -29,8 +29,13 @@ package relay
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // ErrInvalidResponse is returned when an invalid response was received from the relay controller
@@ -39,6 +44,23 @@ var ErrInvalidResponse = fmt.Errorf("invalid response")
 // ErrTimedOut is returned when a command to the relay controller times out
 var ErrTimedOut = fmt.Errorf("timed out waiting for response")
 
+// maxResyncBytes bounds how many bytes sendCommand will discard while
+// scanning for the next 0xAA handshake byte before giving up. Without a
+// bound, a stream that keeps producing bytes but never a handshake byte
+// would loop forever instead of returning ErrInvalidResponse.
+const maxResyncBytes = 256
+
+// NumBanks is the number of relay banks a controller exposes.
+const NumBanks = 8
+
+// RelaysPerBank is the number of relays in each bank.
+const RelaysPerBank = 8
+
+// pulseOffTimeout bounds the off command PulseRelay issues once a pulse
+// completes or is canceled, so that command still has a chance to reach the
+// controller even when the caller's context is already done.
+const pulseOffTimeout = 5 * time.Second
+
 //////////////////////
 ///// Controller /////
 //////////////////////
@@ -46,6 +68,15 @@ var ErrTimedOut = fmt.Errorf("timed out waiting for response")
 // A Controller represents a relay controller
 type Controller struct {
 	stream io.ReadWriter
+
+	// mu serializes access to stream so that multiple goroutines can safely
+	// call Controller methods concurrently. Without it, two in-flight
+	// commands could interleave their writes, or one goroutine's read could
+	// consume bytes that belong to another's response.
+	mu sync.Mutex
+
+	logger   atomic.Value // Logger
+	logLevel int32        // Level, accessed atomically
 }
 
 // New creates a new instance of a relay controller.
@@ -54,8 +85,10 @@ type Controller struct {
 // the stream that is used for communication with the relay controller.
 func New(stream io.ReadWriter) *Controller {
 	c := &Controller{
-		stream: stream,
+		stream:   stream,
+		logLevel: int32(LevelInfo),
 	}
+	c.logger.Store(loggerHolder{noopLogger{}})
 	return c
 }
 
@@ -115,6 +148,99 @@ func (c *Controller) TurnOffRelayByBank(ctx context.Context, index uint8, bank u
 	return c.ExecuteCommand(ctx, packet)
 }
 
+// ToggleRelay flips the given relay (1 based indexing) to the opposite of
+// its current state.
+func (c *Controller) ToggleRelay(ctx context.Context, index uint16) error {
+	status, err := c.GetRelayStatus(ctx, index)
+	if err != nil {
+		return err
+	}
+	if status {
+		return c.TurnOffRelay(ctx, index)
+	}
+	return c.TurnOnRelay(ctx, index)
+}
+
+// PulseRelay closes the given relay (1 based indexing) and then opens it
+// again after duration. If ctx is canceled before duration elapses, the
+// relay is still turned off: the off command is issued with a fresh
+// background context so a canceled caller can never leave a relay
+// energized. PulseRelay returns ctx.Err() in that case, after the off
+// command has been sent.
+func (c *Controller) PulseRelay(ctx context.Context, index uint16, duration time.Duration) error {
+	if err := c.TurnOnRelay(ctx, index); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	var canceled error
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		canceled = ctx.Err()
+	}
+
+	offCtx, cancel := context.WithTimeout(context.Background(), pulseOffTimeout)
+	defer cancel()
+	if err := c.TurnOffRelay(offCtx, index); err != nil {
+		return err
+	}
+	return canceled
+}
+
+// TurnOnAllRelays turns on every relay in every bank.
+func (c *Controller) TurnOnAllRelays(ctx context.Context) error {
+	for bank := uint8(0); bank < NumBanks; bank++ {
+		if err := c.SetBankStatus(ctx, bank, 0xFF); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TurnOffAllRelays turns off every relay in every bank.
+func (c *Controller) TurnOffAllRelays(ctx context.Context) error {
+	for bank := uint8(0); bank < NumBanks; bank++ {
+		if err := c.SetBankStatus(ctx, bank, 0x00); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRelays sets every relay to match mask, where mask[i] is the desired
+// state of relay i+1 (1 based indexing, as used by TurnOnRelay). It reads
+// the current status of each bank first and only writes the banks whose
+// status would actually change.
+func (c *Controller) SetRelays(ctx context.Context, mask []bool) error {
+	for bank := uint8(0); bank < NumBanks; bank++ {
+		var want uint8
+		for i := uint8(0); i < RelaysPerBank; i++ {
+			maskIndex := int(bank)*RelaysPerBank + int(i)
+			if maskIndex >= len(mask) {
+				break
+			}
+			if mask[maskIndex] {
+				want |= 1 << i
+			}
+		}
+
+		have, err := c.GetBankStatus(ctx, bank)
+		if err != nil {
+			return err
+		}
+		if have == want {
+			continue
+		}
+		if err := c.SetBankStatus(ctx, bank, want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ReadAD8 reads one of the AD channels with 8 bit granularity (0-255)
 func (c *Controller) ReadAD8(ctx context.Context, channel uint8) (uint8, error) {
 	packet := CreatePacket([]byte{254, 149 + channel})
@@ -131,14 +257,17 @@ func (c *Controller) ReadAllAD8(ctx context.Context) ([]uint8, error) {
 	return c.ExecuteRead(ctx, packet, 8)
 }
 
-// ReadAD10 reads one of the AD channels with 10 bit granularity (0-1024)
+// ReadAD10 reads one of the AD channels and returns a value in the range
+// 0-255. It shares its command byte with ReadAD8 and, like ReadAD8, only requests
+// a single payload byte back, so a single-channel read only carries 8 bits
+// of precision on the wire; use ReadAllAD10 for the full 10 bit reading.
 func (c *Controller) ReadAD10(ctx context.Context, channel uint8) (uint16, error) {
 	packet := CreatePacket([]byte{254, 149 + channel})
 	v, err := c.ExecuteRead(ctx, packet, 1)
-	if err != nil || len(v) < 2 {
+	if err != nil || len(v) < 1 {
 		return 0, err
 	}
-	return parse10Bit(v), nil
+	return uint16(v[0]), nil
 }
 
 // ReadAllAD10 reads all of the AD channels with 10 bit granularity (0-1024)
@@ -183,48 +312,120 @@ func (c *Controller) ExecuteRead(ctx context.Context, packet Packet, responseLen
 	return response.Payload(), nil
 }
 
-func (c *Controller) sendCommand(ctx context.Context, packet Packet, responseLength int) (response Packet, err error) {
-	response = make([]byte, responseLength)
-	done := make(chan struct{})
-
-	go func() {
-		defer func() {
-			close(done)
-		}()
-		var bytesWritten int
-		bytesToWrite := packet
+// sendResult carries a sendCommand outcome across goroutines. Using a
+// dedicated struct (rather than writing to named return values from the
+// background goroutine) avoids a data race when ctx is done before the
+// goroutine finishes and the caller has already moved on.
+type sendResult struct {
+	response Packet
+	err      error
+}
 
-		for len(bytesToWrite) > 0 {
-			bytesWritten, err = c.stream.Write(bytesToWrite)
-			if err != nil {
-				return
-			}
-			bytesToWrite = bytesToWrite[bytesWritten:]
-		}
+func (c *Controller) sendCommand(ctx context.Context, packet Packet, responseLength int) (Packet, error) {
+	c.mu.Lock()
 
-		var bytesRead int
-		var totalBytesRead int
+	results := make(chan sendResult, 1)
 
-		for totalBytesRead < responseLength {
-			bytesRead, err = c.stream.Read(response[totalBytesRead:])
-			if err != nil {
-				return
+	go func() {
+		response, err := c.writeAndRead(packet, responseLength)
+		if errors.Is(err, ErrReconnected) {
+			// The transport dropped and reestablished its connection; retry
+			// the command once instead of surfacing the reconnect to the
+			// caller as a failure.
+			if c.logEnabled(LevelInfo) {
+				c.log().Info("retrying command after transport reconnect", "packet", hex.EncodeToString(packet))
 			}
-			totalBytesRead += bytesRead
+			response, err = c.writeAndRead(packet, responseLength)
 		}
+		results <- sendResult{response, err}
 	}()
 
 	select {
-	case <-done:
-		// Finished
+	case result := <-results:
+		c.mu.Unlock()
+		return result.response, result.err
 	case <-ctx.Done():
-		// Timed out
-		err = ErrTimedOut
+		// The command timed out, but the goroutine above is still writing
+		// to and reading from the stream. mu must stay locked until it
+		// actually finishes, or a subsequent call could start writing to
+		// the same stream concurrently and desynchronize both of them.
+		go func() {
+			<-results
+			c.mu.Unlock()
+		}()
+		return nil, ErrTimedOut
 	}
+}
 
+func (c *Controller) writeAndRead(packet Packet, responseLength int) (Packet, error) {
+	if c.logEnabled(LevelDebug) {
+		c.log().Debug("tx", "packet", hex.EncodeToString(packet))
+	}
+	if err := c.writePacket(packet); err != nil {
+		return nil, err
+	}
+	response, err := c.readFrame(responseLength)
+	if err == nil && c.logEnabled(LevelDebug) {
+		c.log().Debug("rx", "packet", hex.EncodeToString(response))
+	}
 	return response, err
 }
 
+func (c *Controller) writePacket(packet Packet) error {
+	bytesToWrite := packet
+	for len(bytesToWrite) > 0 {
+		bytesWritten, err := c.stream.Write(bytesToWrite)
+		if err != nil {
+			return err
+		}
+		bytesToWrite = bytesToWrite[bytesWritten:]
+	}
+	return nil
+}
+
+// readFrame reads a single response frame of responseLength bytes from the
+// stream. Because a single garbled byte on the wire would otherwise
+// desynchronize every subsequent read, it scans forward for the 0xAA
+// handshake byte, reads the declared frame, and validates it; an invalid
+// frame or stray byte is discarded and scanning resumes, up to
+// maxResyncBytes, before giving up with ErrInvalidResponse.
+func (c *Controller) readFrame(responseLength int) (Packet, error) {
+	discarded := 0
+	handshake := make([]byte, 1)
+
+	for {
+		if discarded >= maxResyncBytes {
+			return nil, ErrInvalidResponse
+		}
+
+		if _, err := io.ReadFull(c.stream, handshake); err != nil {
+			return nil, err
+		}
+		if handshake[0] != 170 {
+			discarded++
+			if c.logEnabled(LevelWarn) {
+				c.log().Warn("discarding unexpected byte while resyncing", "byte", hex.EncodeToString(handshake))
+			}
+			continue
+		}
+
+		frame := make([]byte, responseLength)
+		frame[0] = handshake[0]
+		if _, err := io.ReadFull(c.stream, frame[1:]); err != nil {
+			return nil, err
+		}
+
+		packet := Packet(frame)
+		if packet.IsValid() {
+			return packet, nil
+		}
+		if c.logEnabled(LevelWarn) {
+			c.log().Warn("discarding invalid frame", "frame", hex.EncodeToString(frame))
+		}
+		discarded += responseLength
+	}
+}
+
 //////////////////
 ///// Packet /////
 //////////////////
@@ -37,6 +37,21 @@ import (
 	"github.com/vaelen/go-ncd-relay/relay"
 )
 
+// loopbackStream is a minimal io.ReadWriter used to feed a Controller
+// scripted bytes and capture what it writes, without needing real hardware.
+type loopbackStream struct {
+	toRead  *bytes.Buffer
+	written *bytes.Buffer
+}
+
+func (s *loopbackStream) Read(p []byte) (int, error) {
+	return s.toRead.Read(p)
+}
+
+func (s *loopbackStream) Write(p []byte) (int, error) {
+	return s.written.Write(p)
+}
+
 func TestPacketChecksum(t *testing.T) {
 	expectedChecksum := byte(0xDC)
 	input := []byte{0xAA, 0x04, 0xFE, 0x30, 0x00, 0x00}
@@ -55,6 +70,233 @@ func TestPacket(t *testing.T) {
 	}
 }
 
+func TestSendCommandResync(t *testing.T) {
+	// A handful of stray bytes precede a well-formed response. The reader
+	// must discard them and resynchronize on the 0xAA handshake rather than
+	// treating the garbage as the start of the frame.
+	garbage := []byte{0x01, 0x02, 0x03}
+	response := relay.CreatePacket([]byte{1})
+	stream := &loopbackStream{
+		toRead:  bytes.NewBuffer(append(garbage, response...)),
+		written: &bytes.Buffer{},
+	}
+	controller := relay.New(stream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	status, err := controller.GetRelayStatus(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected resync to recover a valid frame, got error: %v", err)
+	}
+	if !status {
+		t.Errorf("Expected relay status true, got false")
+	}
+}
+
+func TestToggleRelay(t *testing.T) {
+	// GetRelayStatus reports the relay off, so ToggleRelay should turn it on.
+	statusResponse := relay.CreatePacket([]byte{0})
+	ackResponse := relay.CreatePacket([]byte{1})
+	stream := &loopbackStream{
+		toRead:  bytes.NewBuffer(append(append([]byte{}, statusResponse...), ackResponse...)),
+		written: &bytes.Buffer{},
+	}
+	controller := relay.New(stream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := controller.ToggleRelay(ctx, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	written := stream.written.Bytes()
+	if len(written) != 14 {
+		t.Fatalf("Expected two 7 byte commands to be written, got %x", written)
+	}
+	secondCommand := relay.Packet(written[7:14])
+	if secondCommand.Payload()[1] != 48 {
+		t.Errorf("Expected the second command to turn the relay on, got opcode %d", secondCommand.Payload()[1])
+	}
+}
+
+func TestSetRelaysOnlyWritesChangedBanks(t *testing.T) {
+	// Bank 0 already matches the requested mask, bank 1 does not: only bank
+	// 1 should be written.
+	responses := &bytes.Buffer{}
+	for bank := 0; bank < relay.NumBanks; bank++ {
+		status := byte(0x00)
+		if bank == 0 {
+			status = 0x01
+		}
+		responses.Write(relay.CreatePacket([]byte{status}))
+		if bank == 1 {
+			responses.Write(relay.CreatePacket([]byte{1}))
+		}
+	}
+	stream := &loopbackStream{toRead: responses, written: &bytes.Buffer{}}
+	controller := relay.New(stream)
+
+	mask := make([]bool, relay.NumBanks*relay.RelaysPerBank)
+	mask[0] = true // bank 0, bit 0: already on, no write expected
+	mask[8] = true // bank 1, bit 0: currently off, write expected
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := controller.SetRelays(ctx, mask); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// One GetBankStatus (6 bytes) per bank, plus exactly one SetBankStatus
+	// (7 bytes) for the bank that actually needed to change.
+	expectedLength := relay.NumBanks*6 + 7
+	if got := stream.written.Len(); got != expectedLength {
+		t.Errorf("Expected %d bytes written (a GetBankStatus per bank plus one SetBankStatus), got %d", expectedLength, got)
+	}
+}
+
+func TestPulseRelayTurnsOffOnCancel(t *testing.T) {
+	// Queue up acks for both the "on" command PulseRelay issues up front and
+	// the "off" command it must still issue once ctx is canceled.
+	ack := relay.CreatePacket([]byte{1})
+	var acks bytes.Buffer
+	acks.Write(ack)
+	acks.Write(ack)
+	stream := &loopbackStream{toRead: &acks, written: &bytes.Buffer{}}
+	controller := relay.New(stream)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- controller.PulseRelay(ctx, 1, time.Hour)
+	}()
+
+	// Give TurnOnRelay time to be issued before canceling, so the pulse is
+	// actually in its wait when ctx is canceled.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err != context.Canceled {
+			t.Fatalf("Expected PulseRelay to return context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PulseRelay did not return after ctx was canceled")
+	}
+
+	// TurnOnRelay and TurnOffRelay are both 7 byte commands (4 byte payload).
+	// The second one written must be the off command (opcode 47), proving it
+	// went out despite the cancellation.
+	written := stream.written.Bytes()
+	if len(written) != 14 {
+		t.Fatalf("Expected 14 bytes written (an on command and an off command), got %d: %x", len(written), written)
+	}
+	if written[10] != 47 {
+		t.Errorf("Expected the second command's opcode to be 47 (TurnOffRelay), got %d", written[10])
+	}
+}
+
+func TestTurnOnAllRelaysWritesEveryBank(t *testing.T) {
+	ack := relay.CreatePacket([]byte{1})
+	var acks bytes.Buffer
+	for i := 0; i < relay.NumBanks; i++ {
+		acks.Write(ack)
+	}
+	stream := &loopbackStream{toRead: &acks, written: &bytes.Buffer{}}
+	controller := relay.New(stream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := controller.TurnOnAllRelays(ctx); err != nil {
+		t.Fatalf("TurnOnAllRelays: %v", err)
+	}
+
+	// One 7 byte SetBankStatus command per bank, each with status 0xFF.
+	written := stream.written.Bytes()
+	expectedLength := relay.NumBanks * 7
+	if len(written) != expectedLength {
+		t.Fatalf("Expected %d bytes written (a SetBankStatus per bank), got %d", expectedLength, len(written))
+	}
+	for i := 0; i < relay.NumBanks; i++ {
+		if status := written[i*7+4]; status != 0xFF {
+			t.Errorf("Expected bank %d status 0xFF, got %#02x", i, status)
+		}
+	}
+}
+
+func TestTurnOffAllRelaysWritesEveryBank(t *testing.T) {
+	ack := relay.CreatePacket([]byte{1})
+	var acks bytes.Buffer
+	for i := 0; i < relay.NumBanks; i++ {
+		acks.Write(ack)
+	}
+	stream := &loopbackStream{toRead: &acks, written: &bytes.Buffer{}}
+	controller := relay.New(stream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := controller.TurnOffAllRelays(ctx); err != nil {
+		t.Fatalf("TurnOffAllRelays: %v", err)
+	}
+
+	written := stream.written.Bytes()
+	expectedLength := relay.NumBanks * 7
+	if len(written) != expectedLength {
+		t.Fatalf("Expected %d bytes written (a SetBankStatus per bank), got %d", expectedLength, len(written))
+	}
+	for i := 0; i < relay.NumBanks; i++ {
+		if status := written[i*7+4]; status != 0x00 {
+			t.Errorf("Expected bank %d status 0x00, got %#02x", i, status)
+		}
+	}
+}
+
+// recordingLogger records every message passed to it, whatever its level;
+// filtering by level is left to the Controller.
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...interface{}) { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Info(msg string, _ ...interface{})  { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Warn(msg string, _ ...interface{})  { l.messages = append(l.messages, msg) }
+func (l *recordingLogger) Error(msg string, _ ...interface{}) { l.messages = append(l.messages, msg) }
+
+func TestSetLogLevelControlsVerbosity(t *testing.T) {
+	garbage := []byte{0x01}
+	response := relay.CreatePacket([]byte{1})
+	stream := &loopbackStream{
+		toRead:  bytes.NewBuffer(append(garbage, response...)),
+		written: &bytes.Buffer{},
+	}
+	controller := relay.New(stream)
+
+	logger := &recordingLogger{}
+	controller.SetLogger(logger)
+	controller.SetLogLevel(relay.LevelWarn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := controller.GetRelayStatus(ctx, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(logger.messages) == 0 {
+		t.Fatal("Expected the resync to log a warning about the discarded byte")
+	}
+	for _, msg := range logger.messages {
+		if msg == "tx" || msg == "rx" {
+			t.Errorf("Expected Debug level tx/rx messages to be suppressed at LevelWarn, got %q", msg)
+		}
+	}
+}
+
 func ExampleController() {
 
 	serialOptions := serial.OpenOptions{
@@ -0,0 +1,144 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relaytest
+
+import (
+	"sync"
+
+	"github.com/vaelen/go-ncd-relay/relay"
+)
+
+// FakeController models the protocol-level state of a real NCD relay
+// controller: relay.NumBanks banks of relay.RelaysPerBank relays each, plus
+// 8 AD channels. Point relay.New at its Stream() to drive application code
+// that uses *relay.Controller (TurnOnRelay, GetBankStatus, ReadAllAD10, and
+// so on) exactly as it would drive real hardware.
+type FakeController struct {
+	mu     sync.Mutex
+	banks  [relay.NumBanks]uint8
+	ad     [8]uint16
+	stream *FakeStream
+}
+
+// NewFakeController creates a FakeController with every relay off and every
+// AD channel reading zero.
+func NewFakeController() *FakeController {
+	fc := &FakeController{}
+	fc.stream = NewFakeStream()
+	fc.stream.Handle(fc.handle)
+	return fc
+}
+
+// Stream returns the io.ReadWriter to pass to relay.New.
+func (fc *FakeController) Stream() *FakeStream {
+	return fc.stream
+}
+
+// BankStatus returns the current status byte for the given bank, for
+// assertions that don't want to go through a relay.Controller.
+func (fc *FakeController) BankStatus(bank uint8) uint8 {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.banks[bank]
+}
+
+// SetAD sets the raw 10 bit value (0-1023) of the given AD channel (0
+// based), for tests that want to assert against ReadAD10/ReadAllAD10.
+func (fc *FakeController) SetAD(channel int, value uint16) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.ad[channel] = value & 0x3FF
+}
+
+// handle decodes an NCD command packet and returns the response payload a
+// real controller would send, updating the fake's state as a side effect.
+func (fc *FakeController) handle(packet relay.Packet) []byte {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	payload := packet.Payload()
+	if len(payload) < 2 || payload[0] != 254 {
+		return []byte{0}
+	}
+	cmd := payload[1]
+
+	switch {
+	case cmd == 48 || cmd == 47: // turn relay on/off, global 0 based index on the wire
+		index := int(payload[3])<<8 + int(payload[2])
+		fc.setGlobal(index, cmd == 48)
+		return []byte{1}
+	case cmd == 44: // relay status, global 0 based index on the wire
+		index := int(payload[3])<<8 + int(payload[2])
+		if fc.getGlobal(index) {
+			return []byte{1}
+		}
+		return []byte{0}
+	case cmd == 140: // set bank status
+		status, bank := payload[2], payload[3]
+		fc.banks[bank] = status
+		return []byte{1}
+	case cmd == 124: // get bank status
+		bank := payload[2]
+		return []byte{fc.banks[bank]}
+	case cmd == 166: // read all AD channels, 10 bit
+		out := make([]byte, 16)
+		for i, v := range fc.ad {
+			out[i*2] = byte(v >> 8)
+			out[i*2+1] = byte(v)
+		}
+		return out
+	case cmd >= 149 && cmd < 149+8:
+		// Single channel AD read. relay.ReadAD8 and relay.ReadAD10 both send
+		// this exact command byte and both only request a single payload
+		// byte back (see their ExecuteRead calls), so there is nothing in
+		// the packet that distinguishes an 8 bit read from a 10 bit one;
+		// the response must be one byte to match the frame length
+		// Controller actually reads, or the resulting frame gets
+		// truncated and Controller sees ErrInvalidResponse/EOF instead of
+		// a value.
+		channel := int(cmd - 149)
+		return []byte{byte(fc.ad[channel] >> 2)}
+	default:
+		return []byte{0}
+	}
+}
+
+func (fc *FakeController) setGlobal(index int, on bool) {
+	bank := index / relay.RelaysPerBank
+	bit := uint(index % relay.RelaysPerBank)
+	if on {
+		fc.banks[bank] |= 1 << bit
+	} else {
+		fc.banks[bank] &^= 1 << bit
+	}
+}
+
+func (fc *FakeController) getGlobal(index int) bool {
+	bank := index / relay.RelaysPerBank
+	bit := uint(index % relay.RelaysPerBank)
+	return fc.banks[bank]&(1<<bit) != 0
+}
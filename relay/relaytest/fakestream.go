@@ -0,0 +1,138 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+// Package relaytest provides an in-process fake stream and controller for
+// testing code that uses *relay.Controller without real hardware.
+package relaytest
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vaelen/go-ncd-relay/relay"
+)
+
+// FakeStream is an in-memory io.ReadWriter that stands in for a real relay
+// controller connection in tests. Every packet written to it is validated
+// and recorded; callers script what it writes back with Respond,
+// RespondWithPayload, InjectGarbage, or Handle.
+type FakeStream struct {
+	mu       sync.Mutex
+	received []relay.Packet
+	queue    bytes.Buffer
+	handler  func(relay.Packet) []byte
+	delay    time.Duration
+}
+
+// NewFakeStream creates a FakeStream with no scripted responses.
+func NewFakeStream() *FakeStream {
+	return &FakeStream{}
+}
+
+// Written returns every packet that has been written to the stream so far.
+func (f *FakeStream) Written() []relay.Packet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]relay.Packet, len(f.received))
+	copy(out, f.received)
+	return out
+}
+
+// Respond queues a raw packet to be returned by a future Read.
+func (f *FakeStream) Respond(packet relay.Packet) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue.Write(packet)
+}
+
+// RespondWithPayload queues a well-formed response packet built from the
+// given payload.
+func (f *FakeStream) RespondWithPayload(payload []byte) {
+	f.Respond(relay.CreatePacket(payload))
+}
+
+// InjectGarbage queues raw bytes that do not form a valid packet, to
+// exercise a Controller's resync handling.
+func (f *FakeStream) InjectGarbage(b []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue.Write(b)
+}
+
+// Delay makes the next Read block for d before returning data, to exercise a
+// Controller's context-timeout handling.
+func (f *FakeStream) Delay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = d
+}
+
+// Handle installs a function that computes a response payload for every
+// packet written to the stream, instead of (or in addition to) a fixed
+// queue of scripted responses.
+func (f *FakeStream) Handle(handler func(relay.Packet) []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handler = handler
+}
+
+// Write implements io.Writer. It validates the handshake, length, and
+// checksum of the packet before recording it.
+func (f *FakeStream) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	packet := relay.Packet(append([]byte(nil), p...))
+	if !packet.IsValid() {
+		return 0, fmt.Errorf("relaytest: invalid packet written: %x", p)
+	}
+	f.received = append(f.received, packet)
+
+	if f.handler != nil {
+		f.queue.Write(relay.CreatePacket(f.handler(packet)))
+	}
+
+	return len(p), nil
+}
+
+// Read implements io.Reader, returning whatever has been queued by Respond,
+// RespondWithPayload, InjectGarbage, or a Handle callback.
+func (f *FakeStream) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	delay := f.delay
+	f.delay = 0
+	f.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queue.Read(p)
+}
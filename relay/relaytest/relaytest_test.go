@@ -0,0 +1,160 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relaytest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vaelen/go-ncd-relay/relay"
+	"github.com/vaelen/go-ncd-relay/relay/relaytest"
+)
+
+func TestFakeControllerRelayLifecycle(t *testing.T) {
+	fake := relaytest.NewFakeController()
+	controller := relay.New(fake.Stream())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := controller.TurnOnRelay(ctx, 1); err != nil {
+		t.Fatalf("TurnOnRelay: %v", err)
+	}
+
+	status, err := controller.GetRelayStatus(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetRelayStatus: %v", err)
+	}
+	if !status {
+		t.Errorf("Expected relay 1 to be on after TurnOnRelay")
+	}
+
+	bank, err := controller.GetBankStatus(ctx, 0)
+	if err != nil {
+		t.Fatalf("GetBankStatus: %v", err)
+	}
+	if bank != 0x01 {
+		t.Errorf("Expected bank 0 status 0x01, got %#02x", bank)
+	}
+
+	if err := controller.TurnOffRelay(ctx, 1); err != nil {
+		t.Fatalf("TurnOffRelay: %v", err)
+	}
+	if fake.BankStatus(0) != 0x00 {
+		t.Errorf("Expected bank 0 status 0x00 after TurnOffRelay, got %#02x", fake.BankStatus(0))
+	}
+}
+
+func TestFakeControllerReadAD8(t *testing.T) {
+	fake := relaytest.NewFakeController()
+	fake.SetAD(0, 1023)
+	controller := relay.New(fake.Stream())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	value, err := controller.ReadAD8(ctx, 0)
+	if err != nil {
+		t.Fatalf("ReadAD8: %v", err)
+	}
+	if value != 255 {
+		t.Errorf("Expected channel 0 to read 255, got %d", value)
+	}
+}
+
+func TestFakeControllerReadAD10(t *testing.T) {
+	fake := relaytest.NewFakeController()
+	fake.SetAD(0, 900)
+	controller := relay.New(fake.Stream())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	value, err := controller.ReadAD10(ctx, 0)
+	if err != nil {
+		t.Fatalf("ReadAD10: %v", err)
+	}
+	// ReadAD10 only requests a single payload byte on the wire, so it only
+	// ever carries the 8 bit truncated reading FakeController returns for
+	// that command.
+	expected := uint16(900 >> 2)
+	if value != expected {
+		t.Errorf("Expected channel 0 to read %d, got %d", expected, value)
+	}
+}
+
+func TestFakeControllerReadAllAD10(t *testing.T) {
+	fake := relaytest.NewFakeController()
+	fake.SetAD(0, 512)
+	controller := relay.New(fake.Stream())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	values, err := controller.ReadAllAD10(ctx)
+	if err != nil {
+		t.Fatalf("ReadAllAD10: %v", err)
+	}
+	if values[0] != 512 {
+		t.Errorf("Expected channel 0 to read 512, got %d", values[0])
+	}
+}
+
+func TestFakeStreamInjectGarbageExercisesResync(t *testing.T) {
+	stream := relaytest.NewFakeStream()
+	stream.InjectGarbage([]byte{0x01, 0x02, 0x03})
+	stream.RespondWithPayload([]byte{1})
+
+	controller := relay.New(stream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	status, err := controller.GetRelayStatus(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected Controller to resync past injected garbage, got error: %v", err)
+	}
+	if !status {
+		t.Errorf("Expected relay status true")
+	}
+}
+
+func TestFakeStreamDelayTriggersTimeout(t *testing.T) {
+	stream := relaytest.NewFakeStream()
+	stream.RespondWithPayload([]byte{1})
+	stream.Delay(50 * time.Millisecond)
+
+	controller := relay.New(stream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	if _, err := controller.GetRelayStatus(ctx, 1); err != relay.ErrTimedOut {
+		t.Errorf("Expected ErrTimedOut, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay
+
+import (
+	"fmt"
+	"io"
+)
+
+// ErrReconnected is returned by a Transport's Read or Write when the
+// underlying connection failed but was successfully reestablished. It
+// signals Controller to retry the command once rather than surfacing the
+// transient failure to the caller.
+var ErrReconnected = fmt.Errorf("transport reconnected after a connection error")
+
+// A Transport is the underlying communication channel used by a Controller.
+// NCD relay controllers can be reached over serial, TCP, or UDP, and every
+// one of them can drop a connection that needs to be reestablished. Unlike a
+// plain io.ReadWriter, a Transport knows how to (re)open itself, which is
+// what lets WithReconnect recover from a transient I/O error without the
+// caller having to rebuild the stream by hand.
+type Transport interface {
+	io.ReadWriteCloser
+
+	// Open establishes (or reestablishes) the underlying connection. It is
+	// called once by the Dial functions in this package, and again by
+	// WithReconnect after a failed Read or Write.
+	Open() error
+}
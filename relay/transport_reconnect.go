@@ -0,0 +1,131 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// ReconnectCallback is invoked with the current attempt number (starting at
+// 1) and the error that triggered reconnection, each time a
+// reconnectingTransport tries to reopen its connection.
+type ReconnectCallback func(attempt int, err error)
+
+// ReconnectOptions configures the backoff used when reestablishing a
+// connection after a Read or Write error.
+type ReconnectOptions struct {
+	// InitialBackoff is the delay after the first failed reconnect attempt;
+	// the first attempt itself is made immediately. Defaults to 500ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponentially increasing delay between attempts.
+	// Defaults to 30s.
+	MaxBackoff time.Duration
+	// MaxAttempts bounds how many times Open is retried before giving up
+	// and returning the original error. Zero means retry forever.
+	MaxAttempts int
+	// OnReconnect, if set, is called before each attempt.
+	OnReconnect ReconnectCallback
+}
+
+type reconnectingTransport struct {
+	transport Transport
+	options   ReconnectOptions
+
+	mu sync.Mutex
+}
+
+// WithReconnect wraps transport so that a failed Read or Write triggers an
+// automatic reconnect with exponential backoff rather than leaving the
+// caller to rebuild the connection by hand. Once the reconnect succeeds, the
+// failing call returns ErrReconnected so that Controller knows to retry the
+// command once instead of surfacing the transient failure.
+func WithReconnect(transport Transport, options ReconnectOptions) Transport {
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = 500 * time.Millisecond
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = 30 * time.Second
+	}
+	return &reconnectingTransport{transport: transport, options: options}
+}
+
+func (t *reconnectingTransport) Open() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.transport.Open()
+}
+
+func (t *reconnectingTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.transport.Close()
+}
+
+func (t *reconnectingTransport) Read(p []byte) (int, error) {
+	n, err := t.transport.Read(p)
+	if err == nil {
+		return n, nil
+	}
+	return 0, t.reconnect(err)
+}
+
+func (t *reconnectingTransport) Write(p []byte) (int, error) {
+	n, err := t.transport.Write(p)
+	if err == nil {
+		return n, nil
+	}
+	return 0, t.reconnect(err)
+}
+
+// reconnect reopens the underlying transport with exponential backoff. If it
+// succeeds, it returns ErrReconnected so the caller retries its command
+// rather than giving up on cause. If every attempt fails, it returns cause
+// unchanged.
+func (t *reconnectingTransport) reconnect(cause error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	backoff := t.options.InitialBackoff
+	for attempt := 1; t.options.MaxAttempts == 0 || attempt <= t.options.MaxAttempts; attempt++ {
+		if t.options.OnReconnect != nil {
+			t.options.OnReconnect(attempt, cause)
+		}
+
+		if err := t.transport.Open(); err == nil {
+			return ErrReconnected
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > t.options.MaxBackoff {
+			backoff = t.options.MaxBackoff
+		}
+	}
+
+	return cause
+}
@@ -0,0 +1,79 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay
+
+import (
+	"io"
+
+	"github.com/jacobsa/go-serial/serial"
+)
+
+// serialTransport wraps go-serial so a serial connection can participate in
+// the Transport interface (and, in particular, be reconnected by
+// WithReconnect) just like the TCP and UDP transports. Callers that already
+// open a go-serial port directly and pass it to New can keep doing so; this
+// is only needed when reconnect support is wanted.
+type serialTransport struct {
+	options serial.OpenOptions
+	port    io.ReadWriteCloser
+}
+
+// DialSerial opens a Transport to a relay controller over a serial port
+// using the given go-serial options.
+func DialSerial(options serial.OpenOptions) (Transport, error) {
+	t := &serialTransport{options: options}
+	if err := t.Open(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *serialTransport) Open() error {
+	if t.port != nil {
+		t.port.Close()
+		t.port = nil
+	}
+
+	port, err := serial.Open(t.options)
+	if err != nil {
+		return err
+	}
+	t.port = port
+	return nil
+}
+
+func (t *serialTransport) Read(p []byte) (int, error) {
+	return t.port.Read(p)
+}
+
+func (t *serialTransport) Write(p []byte) (int, error) {
+	return t.port.Write(p)
+}
+
+func (t *serialTransport) Close() error {
+	return t.port.Close()
+}
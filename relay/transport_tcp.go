@@ -0,0 +1,85 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay
+
+import (
+	"net"
+	"time"
+)
+
+// TCPOptions configures a TCP transport.
+type TCPOptions struct {
+	// DialTimeout bounds how long Open waits to establish the connection.
+	// Defaults to 5 seconds.
+	DialTimeout time.Duration
+}
+
+type tcpTransport struct {
+	addr    string
+	options TCPOptions
+	conn    net.Conn
+}
+
+// DialTCP opens a Transport to a relay controller listening on addr (for
+// example "192.168.1.50:2101") over raw TCP.
+func DialTCP(addr string, options TCPOptions) (Transport, error) {
+	t := &tcpTransport{addr: addr, options: options}
+	if err := t.Open(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tcpTransport) Open() error {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+
+	timeout := t.options.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", t.addr, timeout)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	return nil
+}
+
+func (t *tcpTransport) Read(p []byte) (int, error) {
+	return t.conn.Read(p)
+}
+
+func (t *tcpTransport) Write(p []byte) (int, error) {
+	return t.conn.Write(p)
+}
+
+func (t *tcpTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,153 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vaelen/go-ncd-relay/relay"
+)
+
+// scriptedTransport is a relay.Transport whose Read and Write can be told to
+// fail a fixed number of times before succeeding, so reconnect/retry logic
+// can be exercised without a real socket or serial port.
+type scriptedTransport struct {
+	mu         sync.Mutex
+	opens      int
+	failWrites int
+	failReads  int
+	toRead     *bytes.Buffer
+	written    *bytes.Buffer
+}
+
+func (t *scriptedTransport) Open() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.opens++
+	return nil
+}
+
+func (t *scriptedTransport) Close() error {
+	return nil
+}
+
+func (t *scriptedTransport) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failWrites > 0 {
+		t.failWrites--
+		return 0, fmt.Errorf("scriptedTransport: write failed")
+	}
+	return t.written.Write(p)
+}
+
+func (t *scriptedTransport) Read(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failReads > 0 {
+		t.failReads--
+		return 0, fmt.Errorf("scriptedTransport: read failed")
+	}
+	return t.toRead.Read(p)
+}
+
+func TestWithReconnectSignalsErrReconnectedAfterReopening(t *testing.T) {
+	inner := &scriptedTransport{failReads: 1, toRead: &bytes.Buffer{}, written: &bytes.Buffer{}}
+	transport := relay.WithReconnect(inner, relay.ReconnectOptions{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})
+
+	_, err := transport.Read(make([]byte, 1))
+	if !errors.Is(err, relay.ErrReconnected) {
+		t.Fatalf("Expected ErrReconnected after a successful reopen, got %v", err)
+	}
+	if inner.opens != 1 {
+		t.Errorf("Expected exactly one reconnect attempt, got %d", inner.opens)
+	}
+}
+
+func TestWithReconnectGivesUpAfterMaxAttempts(t *testing.T) {
+	inner := &scriptedTransport{failWrites: 1, toRead: &bytes.Buffer{}, written: &bytes.Buffer{}}
+	// Open always fails, so WithReconnect must exhaust MaxAttempts and
+	// return the original write error rather than retrying forever.
+	failingOpen := &alwaysFailOpenTransport{scriptedTransport: inner}
+	transport := relay.WithReconnect(failingOpen, relay.ReconnectOptions{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		MaxAttempts:    2,
+	})
+
+	_, err := transport.Write([]byte{1})
+	if err == nil || errors.Is(err, relay.ErrReconnected) {
+		t.Fatalf("Expected the original write error to be returned once reconnect attempts are exhausted, got %v", err)
+	}
+}
+
+// alwaysFailOpenTransport wraps a scriptedTransport so that Open always
+// fails, to exercise WithReconnect's MaxAttempts cutoff.
+type alwaysFailOpenTransport struct {
+	*scriptedTransport
+}
+
+func (t *alwaysFailOpenTransport) Open() error {
+	return fmt.Errorf("alwaysFailOpenTransport: open failed")
+}
+
+func TestControllerRetriesCommandOnceAfterReconnect(t *testing.T) {
+	response := relay.CreatePacket([]byte{1})
+	inner := &scriptedTransport{
+		failWrites: 1,
+		toRead:     bytes.NewBuffer(response),
+		written:    &bytes.Buffer{},
+	}
+	transport := relay.WithReconnect(inner, relay.ReconnectOptions{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})
+	controller := relay.New(transport)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	status, err := controller.GetRelayStatus(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected Controller to retry transparently across the reconnect, got error: %v", err)
+	}
+	if !status {
+		t.Errorf("Expected relay status true")
+	}
+	if inner.opens != 1 {
+		t.Errorf("Expected exactly one reconnect attempt, got %d", inner.opens)
+	}
+}
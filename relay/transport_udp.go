@@ -0,0 +1,86 @@
+/***
+
+MIT License
+
+Copyright (c) 2018 Andrew C. Young
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+***/
+
+package relay
+
+import (
+	"net"
+	"time"
+)
+
+// UDPOptions configures a UDP transport.
+type UDPOptions struct {
+	// DialTimeout bounds how long Open waits to resolve and connect the
+	// socket. Defaults to 5 seconds.
+	DialTimeout time.Duration
+}
+
+type udpTransport struct {
+	addr    string
+	options UDPOptions
+	conn    net.Conn
+}
+
+// DialUDP opens a Transport to a relay controller listening on addr (for
+// example "192.168.1.50:2101") over UDP.
+func DialUDP(addr string, options UDPOptions) (Transport, error) {
+	t := &udpTransport{addr: addr, options: options}
+	if err := t.Open(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *udpTransport) Open() error {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+
+	timeout := t.options.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.Dial("udp", t.addr)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	return nil
+}
+
+func (t *udpTransport) Read(p []byte) (int, error) {
+	return t.conn.Read(p)
+}
+
+func (t *udpTransport) Write(p []byte) (int, error) {
+	return t.conn.Write(p)
+}
+
+func (t *udpTransport) Close() error {
+	return t.conn.Close()
+}